@@ -0,0 +1,188 @@
+package commit
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// hydratorManifestFile is the content-integrity companion to hydratorMetadataFile. Where hydratorMetadataFile is
+// human-readable provenance (repo URL, dry SHA, commands), hydratorManifestFile records a checksum for every file
+// written during hydration so that a downstream reconciler can verify the hydrated tree in Git hasn't been tampered
+// with.
+type hydratorManifestFile struct {
+	DrySHA  string                  `json:"drySha"`
+	RepoURL string                  `json:"repoUrl"`
+	Files   []hydratorManifestEntry `json:"files"`
+}
+
+// hydratorManifestEntry records the checksum of a single file written during hydration, relative to the hydration
+// root.
+type hydratorManifestEntry struct {
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+	SHA512    string `json:"sha512,omitempty"`
+	MediaType string `json:"mediaType"`
+}
+
+// checksumOptions controls how checksumTrackingStorage hashes files as they're written.
+type checksumOptions struct {
+	// SHA512 additionally records a SHA-512 digest for every file, alongside the always-computed SHA-256 digest.
+	SHA512 bool
+}
+
+// checksumTrackingStorage wraps a Storage and records a hydratorManifestEntry for every file written through it,
+// without changing where or how the underlying Storage writes the file. It's safe for concurrent use, since
+// WriteForPaths hydrates paths concurrently.
+type checksumTrackingStorage struct {
+	Storage
+	opts    checksumOptions
+	mu      sync.Mutex
+	entries []hydratorManifestEntry
+}
+
+func newChecksumTrackingStorage(storage Storage, opts checksumOptions) *checksumTrackingStorage {
+	return &checksumTrackingStorage{Storage: storage, opts: opts}
+}
+
+func (s *checksumTrackingStorage) WriteFile(path string, data []byte) error {
+	if err := s.Storage.WriteFile(path, data); err != nil {
+		return err
+	}
+
+	sum256 := sha256.Sum256(data)
+	entry := hydratorManifestEntry{
+		Path:      path,
+		Size:      int64(len(data)),
+		SHA256:    hex.EncodeToString(sum256[:]),
+		MediaType: mediaTypeForPath(path),
+	}
+	if s.opts.SHA512 {
+		sum512 := sha512.Sum512(data)
+		entry.SHA512 = hex.EncodeToString(sum512[:])
+	}
+	s.mu.Lock()
+	s.entries = append(s.entries, entry)
+	s.mu.Unlock()
+	return nil
+}
+
+// OpenWriter implements StreamWriter by teeing the written bytes through a checksum before recording the resulting
+// hydratorManifestEntry on Close. If the wrapped Storage doesn't itself support streaming writes, it returns (nil,
+// nil), matching the package-level OpenWriter's "not supported" signal, so callers fall back to WriteFile instead
+// of treating the lack of streaming support as an error.
+func (s *checksumTrackingStorage) OpenWriter(path string) (io.WriteCloser, error) {
+	sw, ok := s.Storage.(StreamWriter)
+	if !ok {
+		return nil, nil
+	}
+	w, err := sw.OpenWriter(path)
+	if err != nil {
+		return nil, err
+	}
+	cw := &checksumWriter{w: w, path: path, sha256: sha256.New(), storage: s}
+	if s.opts.SHA512 {
+		cw.sha512 = sha512.New()
+	}
+	return cw, nil
+}
+
+// checksumWriter tees writes to an underlying io.WriteCloser through one or two hash.Hash instances, recording a
+// hydratorManifestEntry on its parent checksumTrackingStorage when closed.
+type checksumWriter struct {
+	w       io.WriteCloser
+	path    string
+	size    int64
+	sha256  hash.Hash
+	sha512  hash.Hash
+	storage *checksumTrackingStorage
+}
+
+func (c *checksumWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.size += int64(n)
+	c.sha256.Write(p[:n])
+	if c.sha512 != nil {
+		c.sha512.Write(p[:n])
+	}
+	return n, err
+}
+
+func (c *checksumWriter) Close() error {
+	err := c.w.Close()
+	entry := hydratorManifestEntry{
+		Path:      c.path,
+		Size:      c.size,
+		SHA256:    hex.EncodeToString(c.sha256.Sum(nil)),
+		MediaType: mediaTypeForPath(c.path),
+	}
+	if c.sha512 != nil {
+		entry.SHA512 = hex.EncodeToString(c.sha512.Sum(nil))
+	}
+	c.storage.mu.Lock()
+	c.storage.entries = append(c.storage.entries, entry)
+	c.storage.mu.Unlock()
+	return err
+}
+
+// writeChecksumManifest writes hydrator.manifest.json, recording a checksum for every file tracked by storage, and
+// if signing is configured, writes a detached signature alongside it as hydrator.manifest.json.sig. It writes
+// through backend rather than storage so the manifest (and its signature) aren't themselves checksummed.
+func writeChecksumManifest(backend Storage, storage *checksumTrackingStorage, repoUrl string, drySha string, signing *SigningOptions) error {
+	// Paths are hydrated concurrently, so entries arrive in completion order rather than path order. Sort by path
+	// so that hydrator.manifest.json stays byte-stable across runs.
+	entries := append([]hydratorManifestEntry{}, storage.entries...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	manifest := hydratorManifestFile{
+		DrySHA:  drySha,
+		RepoURL: repoUrl,
+		Files:   entries,
+	}
+	manifestJson, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal hydrator manifest: %w", err)
+	}
+	if err := backend.WriteFile("hydrator.manifest.json", manifestJson); err != nil {
+		return fmt.Errorf("failed to write hydrator manifest: %w", err)
+	}
+
+	if !signing.enabled() {
+		return nil
+	}
+	sig, err := sign(signing, manifestJson)
+	if err != nil {
+		return fmt.Errorf("failed to sign hydrator manifest: %w", err)
+	}
+	if err := backend.WriteFile("hydrator.manifest.json.sig", sig); err != nil {
+		return fmt.Errorf("failed to write hydrator manifest signature: %w", err)
+	}
+	return nil
+}
+
+// mediaTypeForPath returns a best-effort media type for a hydrated file based on its extension, falling back to a
+// generic binary media type for extensions it doesn't recognize.
+func mediaTypeForPath(path string) string {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		return "application/yaml"
+	case ".json":
+		return "application/json"
+	case ".md":
+		return "text/markdown"
+	default:
+		if t := mime.TypeByExtension(filepath.Ext(path)); t != "" {
+			return t
+		}
+		return "application/octet-stream"
+	}
+}
@@ -0,0 +1,21 @@
+package commit
+
+import "runtime"
+
+// HydrateOptions tunes how WriteForPaths fans work out across paths.
+type HydrateOptions struct {
+	// Concurrency is the number of paths hydrated in parallel. If zero or negative, runtime.GOMAXPROCS(0) is used.
+	Concurrency int
+	// Transforms are applied to every manifest, in order, after the default transforms and before the manifest is
+	// sorted and encoded to manifest.yaml. This lets callers register their own mutators (e.g. stripping
+	// environment-specific annotations) without forking the default pipeline.
+	Transforms []ManifestTransform
+}
+
+// concurrency returns the effective worker-pool size for these options.
+func (o HydrateOptions) concurrency() int {
+	if o.Concurrency > 0 {
+		return o.Concurrency
+	}
+	return runtime.GOMAXPROCS(0)
+}
@@ -2,155 +2,295 @@ package commit
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"io"
 	"path"
+	"sort"
+	"strings"
 	"text/template"
 
-	securejoin "github.com/cyphar/filepath-securejoin"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
 	"gopkg.in/yaml.v3"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	"github.com/argoproj/argo-cd/v2/commitserver/apiclient"
 )
 
+// OutputFormat selects how WriteForPaths lays out hydrated output at the destination.
+type OutputFormat string
+
+const (
+	// OutputFormatFiles writes loose manifest.yaml, hydrator.metadata, and README.md files per path. This is the
+	// default, and is what a Git working tree expects.
+	OutputFormatFiles OutputFormat = "files"
+	// OutputFormatOCI packages the per-path manifests and metadata into an OCI image layout (config, layers, and
+	// a top-level index.json) instead of writing loose files.
+	OutputFormatOCI OutputFormat = "oci"
+)
+
 // WriteForPaths writes the manifests, hydrator.metadata, and README.md files for each path in the provided paths. It
-// also writes a root-level hydrator.metadata file containing the repo URL and dry SHA.
-func WriteForPaths(rootPath string, repoUrl string, drySha string, paths []*apiclient.PathDetails) error {
-	// Write the top-level readme.
-	err := writeMetadata(rootPath, hydratorMetadataFile{DrySHA: drySha, RepoURL: repoUrl})
+// also writes a root-level hydrator.metadata file containing the repo URL and dry SHA. Paths are hydrated
+// concurrently, bounded by opts.Concurrency; ctx cancels any remaining work once the first path fails. With
+// OutputFormatOCI, the per-path content is packaged by writeOCIArtifact instead, and neither the root-level
+// hydrator.metadata nor hydrator.manifest.json is written.
+//
+// rootPath is passed to NewStorage to select the destination backend: a bare filesystem path writes to local disk,
+// while an "s3://" or "gs://" prefix pushes to the corresponding object-storage backend. (This is independent of
+// outputFormat: OutputFormatOCI lays out an OCI image locally through whichever backend rootPath selects.)
+//
+// signing configures an optional content-integrity step: a hydrator.manifest.json recording a checksum for every
+// written file is always produced for OutputFormatFiles, and if signing is non-nil and configured with a key or KMS
+// URI, a detached signature is written alongside it as hydrator.manifest.json.sig.
+//
+// templateProvider supplies the README.md template; if nil, the built-in manifestHydrationReadmeTemplate is used.
+// The template is loaded and validated before any path is hydrated, so a bad user template fails the whole
+// operation fast instead of partway through.
+func WriteForPaths(ctx context.Context, rootPath string, repoUrl string, drySha string, outputFormat OutputFormat, signing *SigningOptions, opts HydrateOptions, templateProvider TemplateProvider, paths []*apiclient.PathDetails) error {
+	backend, err := NewStorage(rootPath)
 	if err != nil {
-		return fmt.Errorf("failed to write top-level hydrator metadata: %w", err)
+		return fmt.Errorf("failed to construct storage backend: %w", err)
 	}
 
-	for _, p := range paths {
-		hydratePath := p.Path
-		if hydratePath == "." {
-			hydratePath = ""
-		}
-		var fullHydratePath string
-		fullHydratePath, err = securejoin.SecureJoin(rootPath, hydratePath)
-		if err != nil {
-			return fmt.Errorf("failed to construct hydrate path: %w", err)
+	if templateProvider == nil {
+		templateProvider = defaultTemplateProvider{}
+	}
+	readmeTemplate, err := templateProvider.LoadTemplate(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load README template: %w", err)
+	}
+
+	if outputFormat == OutputFormatOCI {
+		if err := writeOCIArtifact(backend, repoUrl, drySha, readmeTemplate, paths, opts.Transforms...); err != nil {
+			return fmt.Errorf("failed to write OCI artifact: %w", err)
 		}
-		// TODO: consider switching to securejoin.MkdirAll: https://github.com/cyphar/filepath-securejoin?tab=readme-ov-file#mkdirall
-		err = os.MkdirAll(fullHydratePath, os.ModePerm)
-		if err != nil {
-			return fmt.Errorf("failed to create path: %w", err)
+		if err := backend.Commit(ctx); err != nil {
+			return fmt.Errorf("failed to commit OCI artifact: %w", err)
 		}
+		return nil
+	}
 
-		// Write the manifests
-		err = writeManifests(fullHydratePath, p.Manifests)
-		if err != nil {
-			return fmt.Errorf("failed to write manifests: %w", err)
-		}
+	checksumOpts := checksumOptions{}
+	if signing != nil {
+		checksumOpts.SHA512 = signing.SHA512
+	}
+	storage := newChecksumTrackingStorage(backend, checksumOpts)
 
-		// Write hydrator.metadata containing information about the hydration process.
-		hydratorMetadata := hydratorMetadataFile{
-			Commands: p.Commands,
-			DrySHA:   drySha,
-			RepoURL:  repoUrl,
-		}
-		err = writeMetadata(fullHydratePath, hydratorMetadata)
-		if err != nil {
-			return fmt.Errorf("failed to write hydrator metadata: %w", err)
-		}
+	// Write the top-level hydrator.metadata.
+	err = writeMetadata(storage, "", hydratorMetadataFile{DrySHA: drySha, RepoURL: repoUrl})
+	if err != nil {
+		return fmt.Errorf("failed to write top-level hydrator metadata: %w", err)
+	}
 
-		// Write README
-		err = writeReadme(fullHydratePath, hydratorMetadata)
-		if err != nil {
-			return fmt.Errorf("failed to write readme: %w", err)
-		}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(opts.concurrency())
+	errs := make([]error, len(paths))
+	for i, p := range paths {
+		i, p := i, p
+		g.Go(func() error {
+			if err := gctx.Err(); err != nil {
+				return err
+			}
+			errs[i] = hydratePath(storage, p, repoUrl, drySha, readmeTemplate, opts.Transforms...)
+			return errs[i]
+		})
+	}
+	// Wait reports only the first error; the per-index errs slice is what lets us preserve path order below.
+	_ = g.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to hydrate paths: %w", err)
+	}
+
+	if err := writeChecksumManifest(backend, storage, repoUrl, drySha, signing); err != nil {
+		return fmt.Errorf("failed to write hydrator manifest: %w", err)
+	}
+
+	err = backend.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to commit hydrated output: %w", err)
+	}
+	return nil
+}
+
+// hydratePath writes the manifest.yaml, hydrator.metadata, and README.md for a single path.
+func hydratePath(storage Storage, p *apiclient.PathDetails, repoUrl string, drySha string, readmeTemplate *template.Template, transforms ...ManifestTransform) error {
+	dirPath := p.Path
+	if dirPath == "." {
+		dirPath = ""
+	}
+
+	if err := storage.Mkdir(dirPath); err != nil {
+		return fmt.Errorf("failed to create path: %w", err)
+	}
+
+	if err := writeManifests(storage, dirPath, p.Manifests, transforms...); err != nil {
+		return fmt.Errorf("failed to write manifests: %w", err)
+	}
+
+	hydratorMetadata := hydratorMetadataFile{
+		Commands: p.Commands,
+		DrySHA:   drySha,
+		RepoURL:  repoUrl,
+	}
+	if err := writeMetadata(storage, dirPath, hydratorMetadata); err != nil {
+		return fmt.Errorf("failed to write hydrator metadata: %w", err)
+	}
+
+	if err := writeReadme(storage, dirPath, hydratorMetadata, readmeTemplate); err != nil {
+		return fmt.Errorf("failed to write readme: %w", err)
 	}
 	return nil
 }
 
 // writeMetadata writes the metadata to the hydrator.metadata file.
-func writeMetadata(dirPath string, metadata hydratorMetadataFile) error {
-	hydratorMetadataJson, err := json.MarshalIndent(metadata, "", "  ")
+func writeMetadata(storage Storage, dirPath string, metadata hydratorMetadataFile) error {
+	hydratorMetadataJson, err := renderMetadata(metadata)
 	if err != nil {
-		return fmt.Errorf("failed to marshal hydrator metadata: %w", err)
+		return err
 	}
-	// No need to use SecureJoin here, as the path is already sanitized.
-	hydratorMetadataPath := path.Join(dirPath, "hydrator.metadata")
-	err = os.WriteFile(hydratorMetadataPath, hydratorMetadataJson, os.ModePerm)
+	err = storage.WriteFile(path.Join(dirPath, "hydrator.metadata"), hydratorMetadataJson)
 	if err != nil {
 		return fmt.Errorf("failed to write hydrator metadata: %w", err)
 	}
 	return nil
 }
 
-// writeReadme writes the readme to the README.md file.
-func writeReadme(dirPath string, metadata hydratorMetadataFile) error {
-	readmeTemplate := template.New("readme")
-	readmeTemplate, err := readmeTemplate.Parse(manifestHydrationReadmeTemplate)
-	if err != nil {
-		return fmt.Errorf("failed to parse readme template: %w", err)
+// renderMetadata marshals metadata into the JSON contents of a hydrator.metadata file. If metadata.SchemaVersion is
+// unset, it defaults to hydratorMetadataSchemaVersion.
+func renderMetadata(metadata hydratorMetadataFile) ([]byte, error) {
+	if metadata.SchemaVersion == "" {
+		metadata.SchemaVersion = hydratorMetadataSchemaVersion
 	}
-	// Create writer to template into
-	// No need to use SecureJoin here, as the path is already sanitized.
-	readmePath := path.Join(dirPath, "README.md")
-	readmeFile, err := os.Create(readmePath)
-	if err != nil && !os.IsExist(err) {
-		return fmt.Errorf("failed to create README file: %w", err)
+	hydratorMetadataJson, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal hydrator metadata: %w", err)
 	}
-	err = readmeTemplate.Execute(readmeFile, metadata)
-	closeErr := readmeFile.Close()
-	if closeErr != nil {
-		log.WithError(closeErr).Error("failed to close README file")
+	return hydratorMetadataJson, nil
+}
+
+// writeReadme writes the readme to the README.md file.
+func writeReadme(storage Storage, dirPath string, metadata hydratorMetadataFile, readmeTemplate *template.Template) error {
+	readme, err := renderReadme(metadata, readmeTemplate)
+	if err != nil {
+		return err
 	}
+	err = storage.WriteFile(path.Join(dirPath, "README.md"), readme)
 	if err != nil {
-		return fmt.Errorf("failed to execute readme template: %w", err)
+		return fmt.Errorf("failed to write README file: %w", err)
 	}
 	return nil
 }
 
-// writeManifests writes the manifests to the manifest.yaml file, truncating the file if it exists and appending the
-// manifests in the order they are provided.
-func writeManifests(dirPath string, manifests []*apiclient.ManifestDetails) error {
-	// If the file exists, truncate it.
-	// No need to use SecureJoin here, as the path is already sanitized.
+// renderReadme executes readmeTemplate against metadata and returns the resulting Markdown.
+func renderReadme(metadata hydratorMetadataFile, readmeTemplate *template.Template) ([]byte, error) {
+	buf := bytes.Buffer{}
+	err := readmeTemplate.Execute(&buf, metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute readme template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeManifests writes the manifests to the manifest.yaml file, overwriting any existing content. Manifests are
+// transformed and sorted by (namespace, kind, name, apiVersion) via prepareManifests, so they are not written in the
+// order they are provided. When storage supports StreamWriter, the YAML is encoded directly against the destination
+// writer instead of being buffered in memory first.
+func writeManifests(storage Storage, dirPath string, manifests []*apiclient.ManifestDetails, transforms ...ManifestTransform) error {
+	objs, err := prepareManifests(manifests, transforms...)
+	if err != nil {
+		return err
+	}
+
 	manifestPath := path.Join(dirPath, "manifest.yaml")
-	if _, err := os.Stat(manifestPath); err == nil {
-		err = os.Truncate(manifestPath, 0)
-		if err != nil {
-			return fmt.Errorf("failed to empty manifest file: %w", err)
+	w, err := OpenWriter(storage, manifestPath)
+	if err != nil {
+		return fmt.Errorf("failed to open manifest file for streaming: %w", err)
+	}
+	if w != nil {
+		defer func() {
+			if closeErr := w.Close(); closeErr != nil {
+				log.WithError(closeErr).Error("failed to close manifest file")
+			}
+		}()
+		if err := encodeManifests(w, objs); err != nil {
+			return err
 		}
+		return nil
 	}
 
-	file, err := os.OpenFile(manifestPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.ModePerm)
+	buf := bytes.Buffer{}
+	if err := encodeManifests(&buf, objs); err != nil {
+		return err
+	}
+	if err := storage.WriteFile(manifestPath, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write manifest file: %w", err)
+	}
+	return nil
+}
+
+// renderManifests marshals manifests into the concatenated YAML contents of a manifest.yaml file. Manifests are
+// sorted by (namespace, kind, name, apiVersion) and passed through the default transforms (plus any caller-supplied
+// transforms) before being encoded, so that the output is byte-stable across runs given the same inputs — see
+// defaultManifestTransforms for why that matters.
+func renderManifests(manifests []*apiclient.ManifestDetails, transforms ...ManifestTransform) ([]byte, error) {
+	objs, err := prepareManifests(manifests, transforms...)
 	if err != nil {
-		return fmt.Errorf("failed to open manifest file: %w", err)
+		return nil, err
 	}
-	defer func() {
-		err := file.Close()
-		if err != nil {
-			log.WithError(err).Error("failed to close file")
-		}
-	}()
-	for _, m := range manifests {
+	buf := bytes.Buffer{}
+	if err := encodeManifests(&buf, objs); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// prepareManifests unmarshals manifests, applies the default transforms plus any caller-supplied transforms, and
+// sorts the result by (namespace, kind, name, apiVersion) ready for encoding.
+func prepareManifests(manifests []*apiclient.ManifestDetails, transforms ...ManifestTransform) ([]*unstructured.Unstructured, error) {
+	objs := make([]*unstructured.Unstructured, len(manifests))
+	for i, m := range manifests {
 		obj := &unstructured.Unstructured{}
-		err = json.Unmarshal([]byte(m.Manifest), obj)
+		err := json.Unmarshal([]byte(m.Manifest), obj)
 		if err != nil {
-			return fmt.Errorf("failed to unmarshal manifest: %w", err)
+			return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
 		}
-		// Marshal the manifests
-		buf := bytes.Buffer{}
-		enc := yaml.NewEncoder(&buf)
-		enc.SetIndent(2)
-		err = enc.Encode(&obj.Object)
-		if err != nil {
+		objs[i] = obj
+	}
+
+	allTransforms := append(append([]ManifestTransform{}, defaultManifestTransforms...), transforms...)
+	for _, obj := range objs {
+		for _, transform := range allTransforms {
+			if err := transform(obj); err != nil {
+				return nil, fmt.Errorf("failed to transform manifest %s/%s: %w", obj.GetNamespace(), obj.GetName(), err)
+			}
+		}
+	}
+
+	sort.Slice(objs, func(i, j int) bool {
+		return manifestSortKey(objs[i]) < manifestSortKey(objs[j])
+	})
+	return objs, nil
+}
+
+// encodeManifests writes objs to w as concatenated YAML documents.
+func encodeManifests(w io.Writer, objs []*unstructured.Unstructured) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	for _, obj := range objs {
+		if err := enc.Encode(&obj.Object); err != nil {
 			return fmt.Errorf("failed to encode manifest: %w", err)
 		}
-		mYaml := buf.Bytes()
-		mYaml = append(mYaml, []byte("\n---\n\n")...)
-		// Write the yaml to manifest.yaml
-		_, err = file.Write(mYaml)
-		if err != nil {
-			return fmt.Errorf("failed to write manifest: %w", err)
+		if _, err := io.WriteString(w, "\n---\n\n"); err != nil {
+			return fmt.Errorf("failed to write manifest separator: %w", err)
 		}
 	}
 	return nil
 }
+
+// manifestSortKey builds the (namespace, kind, name, apiVersion) key that manifests are ordered by.
+func manifestSortKey(obj *unstructured.Unstructured) string {
+	return strings.Join([]string{obj.GetNamespace(), obj.GetKind(), obj.GetName(), obj.GetAPIVersion()}, "/")
+}
@@ -0,0 +1,100 @@
+package commit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/argoproj/argo-cd/v2/commitserver/apiclient"
+)
+
+// fakeNonStreamingStorage is an in-memory Storage that deliberately does NOT implement StreamWriter, so it
+// exercises the same code path a real S3/GCS backend does.
+type fakeNonStreamingStorage struct {
+	files map[string][]byte
+}
+
+func newFakeNonStreamingStorage() *fakeNonStreamingStorage {
+	return &fakeNonStreamingStorage{files: map[string][]byte{}}
+}
+
+func (s *fakeNonStreamingStorage) WriteFile(path string, data []byte) error {
+	s.files[path] = append([]byte{}, data...)
+	return nil
+}
+
+func (s *fakeNonStreamingStorage) Mkdir(_ string) error { return nil }
+
+func (s *fakeNonStreamingStorage) Commit(_ context.Context) error { return nil }
+
+// TestWriteManifests_FallsBackForNonStreamingStorage guards against a regression where wrapping a backend that
+// doesn't support StreamWriter (e.g. S3, GCS) in checksumTrackingStorage caused writeManifests to treat the lack of
+// streaming support as a fatal error instead of falling back to WriteFile.
+func TestWriteManifests_FallsBackForNonStreamingStorage(t *testing.T) {
+	backend := newFakeNonStreamingStorage()
+	storage := newChecksumTrackingStorage(backend, checksumOptions{})
+
+	manifests := []*apiclient.ManifestDetails{
+		{Manifest: `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm","namespace":"default"},"data":{"k":"v"}}`},
+	}
+
+	err := writeManifests(storage, "app", manifests)
+	require.NoError(t, err)
+
+	data, ok := backend.files["app/manifest.yaml"]
+	require.True(t, ok, "expected manifest.yaml to be written via WriteFile fallback")
+	assert.Contains(t, string(data), "name: cm")
+}
+
+// TestWriteManifests_AppliesCallerSuppliedTransforms confirms that caller-supplied transforms passed to
+// writeManifests are applied to each manifest before it is written to manifest.yaml.
+func TestWriteManifests_AppliesCallerSuppliedTransforms(t *testing.T) {
+	backend := newFakeNonStreamingStorage()
+	storage := newChecksumTrackingStorage(backend, checksumOptions{})
+
+	manifests := []*apiclient.ManifestDetails{
+		{Manifest: `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm","namespace":"default"},"data":{"k":"v"}}`},
+	}
+
+	stripData := func(obj *unstructured.Unstructured) error {
+		unstructured.RemoveNestedField(obj.Object, "data")
+		return nil
+	}
+
+	err := writeManifests(storage, "app", manifests, stripData)
+	require.NoError(t, err)
+
+	data, ok := backend.files["app/manifest.yaml"]
+	require.True(t, ok)
+	assert.NotContains(t, string(data), "k: v")
+}
+
+// TestWriteForPaths_SequentialFailureCancelsRemainingPaths confirms that with HydrateOptions{Concurrency: 1}, paths
+// are hydrated in order, a failure on one path is surfaced deterministically through errors.Join, and later paths
+// are cancelled (and so never write output) rather than racing ahead independently.
+func TestWriteForPaths_SequentialFailureCancelsRemainingPaths(t *testing.T) {
+	rootPath := t.TempDir()
+	paths := []*apiclient.PathDetails{
+		{Path: "bad", Manifests: []*apiclient.ManifestDetails{{Manifest: `not valid json`}}},
+		{Path: "good1", Manifests: []*apiclient.ManifestDetails{
+			{Manifest: `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm1","namespace":"default"}}`},
+		}},
+		{Path: "good2", Manifests: []*apiclient.ManifestDetails{
+			{Manifest: `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm2","namespace":"default"}}`},
+		}},
+	}
+
+	err := WriteForPaths(context.Background(), rootPath, "https://github.com/example/repo.git", "abc123",
+		OutputFormatFiles, nil, HydrateOptions{Concurrency: 1}, nil, paths)
+	require.Error(t, err)
+
+	for _, p := range []string{"good1", "good2"} {
+		_, statErr := os.Stat(filepath.Join(rootPath, p, "manifest.yaml"))
+		assert.True(t, os.IsNotExist(statErr), "expected %s to be cancelled before it wrote manifest.yaml", p)
+	}
+}
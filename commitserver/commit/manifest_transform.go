@@ -0,0 +1,58 @@
+package commit
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ManifestTransform mutates a single manifest in place before it is serialized to manifest.yaml. Transforms run in
+// order after the manifest is unmarshalled and before it is sorted and encoded, so they can rely on obj being fully
+// populated but must not assume any particular ordering of other manifests in the same path.
+type ManifestTransform func(obj *unstructured.Unstructured) error
+
+// defaultManifestTransforms strip fields that are volatile (i.e. they vary across otherwise-identical hydration
+// runs) so that writeManifests produces byte-stable output given the same inputs. Without this, every hydration run
+// would produce a spurious commit diff even when nothing meaningful changed.
+var defaultManifestTransforms = []ManifestTransform{
+	stripCreationTimestamp,
+	stripEmptyStatus,
+	stripLastAppliedConfigurationAnnotation,
+}
+
+// stripCreationTimestamp removes the null metadata.creationTimestamp field that Kubernetes serializers add to
+// every object.
+func stripCreationTimestamp(obj *unstructured.Unstructured) error {
+	unstructured.RemoveNestedField(obj.Object, "metadata", "creationTimestamp")
+	return nil
+}
+
+// stripEmptyStatus removes an empty status subresource, which is present on most live-read objects but meaningless
+// for a manifest that's about to be applied.
+func stripEmptyStatus(obj *unstructured.Unstructured) error {
+	status, found, err := unstructured.NestedMap(obj.Object, "status")
+	if err != nil {
+		return err
+	}
+	if found && len(status) == 0 {
+		unstructured.RemoveNestedField(obj.Object, "status")
+	}
+	return nil
+}
+
+// stripLastAppliedConfigurationAnnotation removes the kubectl.kubernetes.io/last-applied-configuration annotation,
+// and removes the annotations map entirely if doing so leaves it empty. This annotation is populated by kubectl
+// apply and has no place in a hydrated manifest.
+func stripLastAppliedConfigurationAnnotation(obj *unstructured.Unstructured) error {
+	annotations, found, err := unstructured.NestedStringMap(obj.Object, "metadata", "annotations")
+	if err != nil || !found {
+		return err
+	}
+	if _, ok := annotations["kubectl.kubernetes.io/last-applied-configuration"]; !ok {
+		return nil
+	}
+	delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(obj.Object, "metadata", "annotations")
+		return nil
+	}
+	return unstructured.SetNestedStringMap(obj.Object, annotations, "metadata", "annotations")
+}
@@ -0,0 +1,62 @@
+package commit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// TestStripLastAppliedConfigurationAnnotation_RemovesPopulatedValue guards against a regression where the condition
+// was inverted, so the annotation was only stripped when it was already empty and left untouched when it actually
+// carried the kubectl-apply JSON blob it exists to strip.
+func TestStripLastAppliedConfigurationAnnotation_RemovesPopulatedValue(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				"kubectl.kubernetes.io/last-applied-configuration": `{"apiVersion":"v1","kind":"ConfigMap"}`,
+				"other": "keep-me",
+			},
+		},
+	}}
+
+	require.NoError(t, stripLastAppliedConfigurationAnnotation(obj))
+
+	annotations, found, err := unstructured.NestedStringMap(obj.Object, "metadata", "annotations")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.NotContains(t, annotations, "kubectl.kubernetes.io/last-applied-configuration")
+	assert.Equal(t, "keep-me", annotations["other"])
+}
+
+// TestStripLastAppliedConfigurationAnnotation_RemovesAnnotationsMapWhenEmptied guards against a regression in the
+// cleanup path: when the annotation is the only one present, the whole annotations map should be removed too.
+func TestStripLastAppliedConfigurationAnnotation_RemovesAnnotationsMapWhenEmptied(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				"kubectl.kubernetes.io/last-applied-configuration": `{"apiVersion":"v1","kind":"ConfigMap"}`,
+			},
+		},
+	}}
+
+	require.NoError(t, stripLastAppliedConfigurationAnnotation(obj))
+
+	_, found, err := unstructured.NestedMap(obj.Object, "metadata", "annotations")
+	require.NoError(t, err)
+	assert.False(t, found, "expected empty annotations map to be removed")
+}
+
+// TestStripLastAppliedConfigurationAnnotation_NoAnnotations is a no-op when there are no annotations at all.
+func TestStripLastAppliedConfigurationAnnotation_NoAnnotations(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{},
+	}}
+
+	require.NoError(t, stripLastAppliedConfigurationAnnotation(obj))
+
+	_, found, err := unstructured.NestedMap(obj.Object, "metadata", "annotations")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
@@ -0,0 +1,183 @@
+package commit
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"text/template"
+
+	"github.com/argoproj/argo-cd/v2/commitserver/apiclient"
+)
+
+const (
+	// ociHydratedConfigMediaType is the media type of the config blob embedded in a hydrated-output OCI artifact.
+	ociHydratedConfigMediaType = "application/vnd.argocd.hydrated.v1+json"
+	// ociHydratedLayerMediaType is the media type of each per-path layer blob.
+	ociHydratedLayerMediaType = "application/vnd.argocd.hydrated.layer.v1.tar+gzip"
+	ociImageManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	ociImageIndexMediaType    = "application/vnd.oci.image.index.v1+json"
+)
+
+// ociDescriptor mirrors the OCI content descriptor: media type, digest, and size of a blob.
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// ociHydratedConfig is the config blob embedded in a hydrated-output OCI artifact. It carries the same provenance
+// information as hydrator.metadata, keyed by path, so that a single artifact can describe a whole hydration.
+type ociHydratedConfig struct {
+	DrySHA  string                     `json:"drySha"`
+	RepoURL string                     `json:"repoUrl"`
+	Paths   map[string]ociHydratedPath `json:"paths"`
+}
+
+// ociHydratedPath records the commands that produced the hydrated output for a single path.
+type ociHydratedPath struct {
+	Commands []string `json:"commands"`
+}
+
+// ociImageManifest is a minimal OCI image manifest: a config blob plus a list of layer blobs.
+type ociImageManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Config        ociDescriptor   `json:"config"`
+	Layers        []ociDescriptor `json:"layers"`
+}
+
+// ociImageIndex is a minimal OCI image index: a list of manifest descriptors.
+type ociImageIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+// writeOCIArtifact packages the manifests, hydrator.metadata, and README.md for each path into an OCI image layout:
+// one gzipped tar layer per path, a config blob describing the hydration as a whole, an image manifest referencing
+// them, and a top-level index.json referencing the image manifest. Blobs are content-addressed under
+// blobs/sha256/<digest>, per the OCI image layout spec.
+func writeOCIArtifact(storage Storage, repoUrl string, drySha string, readmeTemplate *template.Template, paths []*apiclient.PathDetails, transforms ...ManifestTransform) error {
+	config := ociHydratedConfig{
+		DrySHA:  drySha,
+		RepoURL: repoUrl,
+		Paths:   map[string]ociHydratedPath{},
+	}
+
+	layers := make([]ociDescriptor, 0, len(paths))
+	for _, p := range paths {
+		layer, err := buildPathLayer(p, repoUrl, drySha, readmeTemplate, transforms...)
+		if err != nil {
+			return fmt.Errorf("failed to build layer for path %q: %w", p.Path, err)
+		}
+		descriptor, err := writeOCIBlob(storage, ociHydratedLayerMediaType, layer)
+		if err != nil {
+			return fmt.Errorf("failed to write layer blob for path %q: %w", p.Path, err)
+		}
+		layers = append(layers, descriptor)
+		config.Paths[p.Path] = ociHydratedPath{Commands: p.Commands}
+	}
+
+	configJson, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal OCI config: %w", err)
+	}
+	configDescriptor, err := writeOCIBlob(storage, ociHydratedConfigMediaType, configJson)
+	if err != nil {
+		return fmt.Errorf("failed to write config blob: %w", err)
+	}
+
+	manifest := ociImageManifest{
+		SchemaVersion: 2,
+		MediaType:     ociImageManifestMediaType,
+		Config:        configDescriptor,
+		Layers:        layers,
+	}
+	manifestJson, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal OCI image manifest: %w", err)
+	}
+	manifestDescriptor, err := writeOCIBlob(storage, manifest.MediaType, manifestJson)
+	if err != nil {
+		return fmt.Errorf("failed to write image manifest blob: %w", err)
+	}
+
+	index := ociImageIndex{
+		SchemaVersion: 2,
+		MediaType:     ociImageIndexMediaType,
+		Manifests:     []ociDescriptor{manifestDescriptor},
+	}
+	indexJson, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal OCI image index: %w", err)
+	}
+	if err := storage.WriteFile("index.json", indexJson); err != nil {
+		return fmt.Errorf("failed to write index.json: %w", err)
+	}
+	return nil
+}
+
+// buildPathLayer renders the manifests, hydrator.metadata, and README.md for a single path and packages them as a
+// gzip-compressed tar archive, matching the layer format of an OCI image. repoUrl and drySha are carried into the
+// per-path hydrator.metadata and README.md, matching what hydratePath writes for the non-OCI output format.
+func buildPathLayer(p *apiclient.PathDetails, repoUrl string, drySha string, readmeTemplate *template.Template, transforms ...ManifestTransform) ([]byte, error) {
+	manifestYaml, err := renderManifests(p.Manifests, transforms...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render manifests: %w", err)
+	}
+	metadata := hydratorMetadataFile{Commands: p.Commands, DrySHA: drySha, RepoURL: repoUrl}
+	metadataJson, err := renderMetadata(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render metadata: %w", err)
+	}
+	readme, err := renderReadme(metadata, readmeTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render readme: %w", err)
+	}
+
+	// A fixed order, rather than a map, keeps the tar (and therefore the layer's sha256 digest) byte-stable across
+	// repeated calls with identical input; Go map iteration order is randomized per-iteration.
+	layerFiles := []struct {
+		name    string
+		content []byte
+	}{
+		{"manifest.yaml", manifestYaml},
+		{"hydrator.metadata", metadataJson},
+		{"README.md", readme},
+	}
+
+	buf := &bytes.Buffer{}
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+	for _, f := range layerFiles {
+		if err := tw.WriteHeader(&tar.Header{Name: path.Join(p.Path, f.name), Mode: 0o644, Size: int64(len(f.content))}); err != nil {
+			return nil, fmt.Errorf("failed to write tar header for %q: %w", f.name, err)
+		}
+		if _, err := tw.Write(f.content); err != nil {
+			return nil, fmt.Errorf("failed to write tar content for %q: %w", f.name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close tar writer: %w", err)
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close gzip writer: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeOCIBlob writes data to the content-addressed blob store and returns its descriptor.
+func writeOCIBlob(storage Storage, mediaType string, data []byte) (ociDescriptor, error) {
+	sum := sha256.Sum256(data)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	blobPath := path.Join("blobs", "sha256", hex.EncodeToString(sum[:]))
+	if err := storage.WriteFile(blobPath, data); err != nil {
+		return ociDescriptor{}, fmt.Errorf("failed to write blob: %w", err)
+	}
+	return ociDescriptor{MediaType: mediaType, Digest: digest, Size: int64(len(data))}, nil
+}
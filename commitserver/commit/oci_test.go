@@ -0,0 +1,69 @@
+package commit
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/argoproj/argo-cd/v2/commitserver/apiclient"
+)
+
+// TestBuildPathLayer_PopulatesRepoURLAndDrySHA guards against a regression where the per-path hydrator.metadata
+// packed into an OCI layer left DrySHA and RepoURL blank, even though both are available to writeOCIArtifact.
+func TestBuildPathLayer_PopulatesRepoURLAndDrySHA(t *testing.T) {
+	readmeTemplate, err := template.New("readme").Parse("Dry SHA: {{.DrySHA}}\nRepo URL: {{.RepoURL}}\n")
+	require.NoError(t, err)
+
+	p := &apiclient.PathDetails{Path: "app"}
+
+	layer, err := buildPathLayer(p, "https://github.com/example/repo.git", "abc123", readmeTemplate)
+	require.NoError(t, err)
+
+	files := untar(t, layer)
+	require.Contains(t, string(files["app/hydrator.metadata"]), `"drySha": "abc123"`)
+	require.Contains(t, string(files["app/hydrator.metadata"]), `"repoUrl": "https://github.com/example/repo.git"`)
+	require.Contains(t, string(files["app/README.md"]), "Dry SHA: abc123")
+	require.Contains(t, string(files["app/README.md"]), "Repo URL: https://github.com/example/repo.git")
+}
+
+// TestBuildPathLayer_IsByteStable guards against a regression where the layer's files were packed into the tar by
+// ranging over a map, making the tar bytes (and therefore the layer's sha256 digest recorded in index.json)
+// non-deterministic across otherwise-identical calls.
+func TestBuildPathLayer_IsByteStable(t *testing.T) {
+	readmeTemplate, err := template.New("readme").Parse("Dry SHA: {{.DrySHA}}\n")
+	require.NoError(t, err)
+
+	p := &apiclient.PathDetails{Path: "app", Commands: []string{"kustomize build ."}}
+
+	first, err := buildPathLayer(p, "https://github.com/example/repo.git", "abc123", readmeTemplate)
+	require.NoError(t, err)
+	second, err := buildPathLayer(p, "https://github.com/example/repo.git", "abc123", readmeTemplate)
+	require.NoError(t, err)
+
+	require.Equal(t, first, second, "buildPathLayer should produce identical bytes for identical input")
+}
+
+// untar reads a gzip-compressed tar archive into a map of file name to contents.
+func untar(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	gzr, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tr := tar.NewReader(gzr)
+	files := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[hdr.Name] = content
+	}
+	return files
+}
@@ -0,0 +1,74 @@
+package commit
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"fmt"
+	"os"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/kms"
+
+	// Blank-imported so their "awskms://" and "gcpkms://" URI schemes register with kms.Get.
+	_ "github.com/sigstore/sigstore/pkg/signature/kms/aws"
+	_ "github.com/sigstore/sigstore/pkg/signature/kms/gcp"
+)
+
+// SigningOptions configures the optional signing step performed over hydrator.manifest.json. Exactly one of KeyPath
+// or KMSURI should be set; if neither is set, signing is skipped.
+type SigningOptions struct {
+	// SHA512 additionally records a SHA-512 digest for every hydrated file, alongside the always-computed SHA-256
+	// digest, in hydrator.manifest.json.
+	SHA512 bool
+	// KeyPath is a path to a local PEM-encoded private key (ECDSA, RSA, or Ed25519) used to sign
+	// hydrator.manifest.json.
+	KeyPath string
+	// KMSURI is a KMS URI (e.g. "awskms://...", "gcpkms://...") identifying a key that sign should use as an
+	// alternative to a local KeyPath.
+	KMSURI string
+}
+
+// enabled reports whether the caller asked for hydrator.manifest.json to be signed.
+func (o *SigningOptions) enabled() bool {
+	return o != nil && (o.KeyPath != "" || o.KMSURI != "")
+}
+
+// sign produces a detached signature over data using the configured key or KMS URI.
+func sign(opts *SigningOptions, data []byte) ([]byte, error) {
+	signer, err := loadSigner(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signer: %w", err)
+	}
+	sig, err := signer.SignMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign hydrator manifest: %w", err)
+	}
+	return sig, nil
+}
+
+// loadSigner resolves a sigstore signer from either a KMS URI or a local PEM-encoded key file.
+func loadSigner(opts *SigningOptions) (signature.Signer, error) {
+	if opts.KMSURI != "" {
+		signer, err := kms.Get(context.Background(), opts.KMSURI, crypto.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load KMS signer for %q: %w", opts.KMSURI, err)
+		}
+		return signer, nil
+	}
+
+	pemBytes, err := os.ReadFile(opts.KeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file %q: %w", opts.KeyPath, err)
+	}
+	priv, err := cryptoutils.UnmarshalPEMToPrivateKey(pemBytes, cryptoutils.SkipPassword)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %q: %w", opts.KeyPath, err)
+	}
+	signer, err := signature.LoadSigner(priv, crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signer from key file %q: %w", opts.KeyPath, err)
+	}
+	return signer, nil
+}
@@ -0,0 +1,38 @@
+package commit
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSign_KeyFileRoundTrips guards against a regression where loadSigner called cosign APIs that don't exist on the
+// sigstore/cosign module (KMSSigner, SignerFromKeyFile), which would fail to build. It signs over a real ECDSA key
+// file and verifies the signature with the matching public key.
+func TestSign_KeyFileRoundTrips(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	pemBytes, err := cryptoutils.MarshalPrivateKeyToPEM(priv)
+	require.NoError(t, err)
+
+	keyPath := filepath.Join(t.TempDir(), "signing-key.pem")
+	require.NoError(t, os.WriteFile(keyPath, pemBytes, 0o600))
+
+	data := []byte(`{"drySha":"abc123"}`)
+	sig, err := sign(&SigningOptions{KeyPath: keyPath}, data)
+	require.NoError(t, err)
+
+	verifier, err := signature.LoadVerifier(priv.Public(), crypto.SHA256)
+	require.NoError(t, err)
+	require.NoError(t, verifier.VerifySignature(bytes.NewReader(sig), bytes.NewReader(data)))
+}
@@ -0,0 +1,129 @@
+package commit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+)
+
+// Storage abstracts the destination that hydrated manifests, metadata, and READMEs are written to. Implementations
+// may write to a local filesystem or an object store (S3, GCS). WriteForPaths and its helpers write through this
+// interface instead of assuming a local rootPath, so hydrated output can be pushed directly to object storage
+// without a local Git clone.
+type Storage interface {
+	// WriteFile writes data to path, which is relative to the storage backend's configured destination.
+	WriteFile(path string, data []byte) error
+	// Mkdir ensures that path, and any necessary parents, exist. For backends with no directory concept (e.g.
+	// object storage), this is typically a no-op.
+	Mkdir(path string) error
+	// Commit finalizes the write, e.g. flushing an upload or committing a local git working tree. For backends
+	// that write immediately, this may be a no-op.
+	Commit(ctx context.Context) error
+}
+
+// StreamWriter is an optional capability a Storage backend can implement to expose a direct writer for a path,
+// letting callers encode large content straight to the destination instead of building the whole file in memory
+// and calling WriteFile. Backends for which streaming isn't meaningful (e.g. backends that buffer a whole object
+// before upload) can simply not implement it; callers fall back to WriteFile in that case.
+type StreamWriter interface {
+	// OpenWriter returns a writer for path. The caller must Close it to finalize the write.
+	OpenWriter(path string) (io.WriteCloser, error)
+}
+
+// OpenWriter opens a direct writer for path if storage is a StreamWriter, and returns nil otherwise.
+func OpenWriter(storage Storage, path string) (io.WriteCloser, error) {
+	sw, ok := storage.(StreamWriter)
+	if !ok {
+		return nil, nil
+	}
+	return sw.OpenWriter(path)
+}
+
+// NewStorage constructs a Storage backend for the given destination. The scheme of destination selects the
+// backend: "s3://" for S3, "gs://" for GCS, and anything else (including a bare filesystem path) for the local
+// filesystem.
+//
+// There's intentionally no "oci://" backend yet: pushing hydrated output as an OCI registry artifact needs real
+// registry-push support, not a stub that always fails at Commit, so it's left for a follow-up once that's built.
+// (OutputFormatOCI is unrelated: it lays out an OCI image locally through whichever backend is selected here.)
+func NewStorage(destination string) (Storage, error) {
+	switch {
+	case strings.HasPrefix(destination, "s3://"):
+		return newS3Storage(strings.TrimPrefix(destination, "s3://"))
+	case strings.HasPrefix(destination, "gs://"):
+		return newGCSStorage(strings.TrimPrefix(destination, "gs://"))
+	default:
+		return newLocalStorage(destination)
+	}
+}
+
+// localStorage writes to a directory on the local filesystem. Paths are joined with the root using SecureJoin to
+// prevent path traversal outside the root.
+type localStorage struct {
+	rootPath string
+}
+
+func newLocalStorage(rootPath string) (Storage, error) {
+	return &localStorage{rootPath: rootPath}, nil
+}
+
+func (s *localStorage) resolve(p string) (string, error) {
+	full, err := securejoin.SecureJoin(s.rootPath, p)
+	if err != nil {
+		return "", fmt.Errorf("failed to construct path under root: %w", err)
+	}
+	return full, nil
+}
+
+func (s *localStorage) WriteFile(p string, data []byte) error {
+	full, err := s.resolve(p)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(full), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create parent directory: %w", err)
+	}
+	if err := os.WriteFile(full, data, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+func (s *localStorage) Mkdir(p string) error {
+	full, err := s.resolve(p)
+	if err != nil {
+		return err
+	}
+	// TODO: consider switching to securejoin.MkdirAll: https://github.com/cyphar/filepath-securejoin?tab=readme-ov-file#mkdirall
+	if err := os.MkdirAll(full, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create path: %w", err)
+	}
+	return nil
+}
+
+func (s *localStorage) Commit(_ context.Context) error {
+	// Files are written immediately, so there's nothing to finalize.
+	return nil
+}
+
+// OpenWriter implements StreamWriter by opening the destination file directly, so large content can be encoded to
+// it without first being held in memory as a byte slice.
+func (s *localStorage) OpenWriter(p string) (io.WriteCloser, error) {
+	full, err := s.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(path.Dir(full), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create parent directory: %w", err)
+	}
+	f, err := os.Create(full)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	return f, nil
+}
@@ -0,0 +1,92 @@
+//go:build storage_remote
+
+package commit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// s3Storage writes hydrated output to an S3 bucket. destination is of the form "<bucket>/<prefix>".
+type s3Storage struct {
+	bucket string
+	prefix string
+	client *s3.Client
+}
+
+func newS3Storage(destination string) (Storage, error) {
+	bucket, prefix, _ := strings.Cut(destination, "/")
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return &s3Storage{bucket: bucket, prefix: prefix, client: s3.NewFromConfig(cfg)}, nil
+}
+
+func (s *s3Storage) WriteFile(p string, data []byte) error {
+	key := path.Join(s.prefix, p)
+	_, err := s.client.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put object %q: %w", key, err)
+	}
+	return nil
+}
+
+func (s *s3Storage) Mkdir(_ string) error {
+	// S3 has no directory concept; keys are created implicitly when objects are written.
+	return nil
+}
+
+func (s *s3Storage) Commit(_ context.Context) error {
+	return nil
+}
+
+// gcsStorage writes hydrated output to a GCS bucket. destination is of the form "<bucket>/<prefix>".
+type gcsStorage struct {
+	bucket string
+	prefix string
+	client *storage.Client
+}
+
+func newGCSStorage(destination string) (Storage, error) {
+	bucket, prefix, _ := strings.Cut(destination, "/")
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+	return &gcsStorage{bucket: bucket, prefix: prefix, client: client}, nil
+}
+
+func (s *gcsStorage) WriteFile(p string, data []byte) error {
+	key := path.Join(s.prefix, p)
+	w := s.client.Bucket(s.bucket).Object(key).NewWriter(context.Background())
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return fmt.Errorf("failed to write object %q: %w", key, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finalize object %q: %w", key, err)
+	}
+	return nil
+}
+
+func (s *gcsStorage) Mkdir(_ string) error {
+	// GCS has no directory concept; object keys are created implicitly when objects are written.
+	return nil
+}
+
+func (s *gcsStorage) Commit(_ context.Context) error {
+	return nil
+}
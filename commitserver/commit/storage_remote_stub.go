@@ -0,0 +1,17 @@
+//go:build !storage_remote
+
+package commit
+
+import "fmt"
+
+// newS3Storage is a stub used when the binary isn't built with the storage_remote build tag, so that default builds
+// of this package don't pull in the AWS SDK.
+func newS3Storage(_ string) (Storage, error) {
+	return nil, fmt.Errorf("S3 storage support is not built into this binary; rebuild with -tags storage_remote")
+}
+
+// newGCSStorage is a stub used when the binary isn't built with the storage_remote build tag, so that default builds
+// of this package don't pull in the GCS SDK.
+func newGCSStorage(_ string) (Storage, error) {
+	return nil, fmt.Errorf("GCS storage support is not built into this binary; rebuild with -tags storage_remote")
+}
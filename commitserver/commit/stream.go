@@ -0,0 +1,70 @@
+package commit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// WriteManifestsFromStream consumes a JSON array of manifests from r and writes them to manifest.yaml under dir,
+// one at a time, without ever materializing the full set in memory. This is meant for paths with very large
+// hydrations (thousands of manifests, or a few very large CRDs) where prepareManifests' upfront unmarshal-all would
+// use too much memory.
+//
+// Because manifests are written as they're read, output is NOT sorted by (namespace, kind, name, apiVersion) the
+// way writeManifests' output is: callers that need deterministic ordering must ensure r yields manifests in the
+// desired order, e.g. by having the repo-server sort before streaming. defaultManifestTransforms are still applied
+// to each manifest as it's read, since that doesn't require seeing the whole set.
+func WriteManifestsFromStream(dir string, r io.Reader) error {
+	manifestPath, err := securejoin.SecureJoin(dir, "manifest.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to construct manifest path: %w", err)
+	}
+	file, err := os.Create(manifestPath)
+	if err != nil {
+		return fmt.Errorf("failed to create manifest file: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			log.WithError(closeErr).Error("failed to close manifest file")
+		}
+	}()
+
+	dec := json.NewDecoder(r)
+	if _, err := dec.Token(); err != nil { // consume the opening '['
+		return fmt.Errorf("failed to read manifest stream: %w", err)
+	}
+
+	enc := yaml.NewEncoder(file)
+	enc.SetIndent(2)
+	for dec.More() {
+		obj := &unstructured.Unstructured{}
+		if err := dec.Decode(obj); err != nil {
+			return fmt.Errorf("failed to decode manifest from stream: %w", err)
+		}
+		for _, transform := range defaultManifestTransforms {
+			if err := transform(obj); err != nil {
+				return fmt.Errorf("failed to transform manifest %s/%s: %w", obj.GetNamespace(), obj.GetName(), err)
+			}
+		}
+		if err := enc.Encode(&obj.Object); err != nil {
+			return fmt.Errorf("failed to encode manifest: %w", err)
+		}
+		if _, err := io.WriteString(file, "\n---\n\n"); err != nil {
+			return fmt.Errorf("failed to write manifest separator: %w", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("failed to close manifest encoder: %w", err)
+	}
+	if _, err := dec.Token(); err != nil { // consume the closing ']'
+		return fmt.Errorf("failed to read manifest stream: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package commit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// TemplateProvider supplies the Go template that writeReadme executes against a hydratorMetadataFile to produce
+// README.md. Implementations let teams customize the generated README (e.g. to include Jira links, change-approver
+// info, or environment banners) without forking the hydration code.
+type TemplateProvider interface {
+	// LoadTemplate returns the parsed README template. It's called once per WriteForPaths call, before any path is
+	// hydrated, so that a bad template fails the whole operation fast rather than partway through.
+	LoadTemplate(ctx context.Context) (*template.Template, error)
+}
+
+// TemplateFuncs are made available to every README template, default or user-supplied.
+var TemplateFuncs = template.FuncMap{
+	"commandLink": commandLink,
+	"shortSha":    shortSha,
+	"timeAgo":     timeAgo,
+}
+
+// commandLink renders a hydrator command as a Markdown link whose text is the command in an inline code span and
+// whose target is label, e.g. {{range .Commands}}{{commandLink "https://example.com/docs" .}}{{end}}.
+func commandLink(label string, command string) string {
+	return fmt.Sprintf("[`%s`](%s)", command, label)
+}
+
+// shortSha truncates a commit SHA to the conventional 7-character short form.
+func shortSha(sha string) string {
+	if len(sha) <= 7 {
+		return sha
+	}
+	return sha[:7]
+}
+
+// timeAgo formats t as a relative duration rounded to the minute, e.g. "3h5m ago", for use in a custom template.
+func timeAgo(t time.Time) string {
+	return strings.TrimSuffix(time.Since(t).Round(time.Minute).String(), "0s") + " ago"
+}
+
+// defaultTemplateProvider serves the built-in manifestHydrationReadmeTemplate.
+type defaultTemplateProvider struct{}
+
+func (defaultTemplateProvider) LoadTemplate(_ context.Context) (*template.Template, error) {
+	return template.New("readme").Funcs(TemplateFuncs).Parse(manifestHydrationReadmeTemplate)
+}
+
+// fileTemplateProvider loads a user-supplied template from a path on disk.
+type fileTemplateProvider struct {
+	Path string
+}
+
+func (p fileTemplateProvider) LoadTemplate(_ context.Context) (*template.Template, error) {
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template file %q: %w", p.Path, err)
+	}
+	tmpl, err := template.New("readme").Funcs(TemplateFuncs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template file %q: %w", p.Path, err)
+	}
+	return tmpl, nil
+}
+
+// configMapTemplateProvider loads a user-supplied template from a key in a ConfigMap.
+type configMapTemplateProvider struct {
+	Clientset kubernetes.Interface
+	Namespace string
+	Name      string
+	Key       string
+}
+
+func (p configMapTemplateProvider) LoadTemplate(ctx context.Context) (*template.Template, error) {
+	cm, err := p.Clientset.CoreV1().ConfigMaps(p.Namespace).Get(ctx, p.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ConfigMap %s/%s: %w", p.Namespace, p.Name, err)
+	}
+	data, ok := configMapData(cm, p.Key)
+	if !ok {
+		return nil, fmt.Errorf("ConfigMap %s/%s has no key %q", p.Namespace, p.Name, p.Key)
+	}
+	tmpl, err := template.New("readme").Funcs(TemplateFuncs).Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template from ConfigMap %s/%s key %q: %w", p.Namespace, p.Name, p.Key, err)
+	}
+	return tmpl, nil
+}
+
+func configMapData(cm *corev1.ConfigMap, key string) (string, bool) {
+	v, ok := cm.Data[key]
+	return v, ok
+}
@@ -0,0 +1,88 @@
+package commit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/argoproj/argo-cd/v2/commitserver/apiclient"
+)
+
+// TestFileTemplateProvider_MalformedTemplateFailsFast asserts the headline guarantee of the template provider
+// design: a bad user template is rejected by LoadTemplate itself, before WriteForPaths ever gets to hydrate a path.
+func TestFileTemplateProvider_MalformedTemplateFailsFast(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "README.md.tmpl")
+	require.NoError(t, os.WriteFile(path, []byte("{{ .Unclosed"), 0o644))
+
+	_, err := fileTemplateProvider{Path: path}.LoadTemplate(context.Background())
+	require.Error(t, err)
+}
+
+// TestFileTemplateProvider_LoadsValidTemplate confirms a well-formed user template loads successfully with
+// TemplateFuncs available.
+func TestFileTemplateProvider_LoadsValidTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "README.md.tmpl")
+	require.NoError(t, os.WriteFile(path, []byte("Dry SHA: {{shortSha .DrySHA}}\n"), 0o644))
+
+	tmpl, err := fileTemplateProvider{Path: path}.LoadTemplate(context.Background())
+	require.NoError(t, err)
+	assert.NotNil(t, tmpl)
+}
+
+// TestConfigMapTemplateProvider_LoadsTemplateFromKey exercises configMapTemplateProvider against a fake
+// kubernetes.Interface.
+func TestConfigMapTemplateProvider_LoadsTemplateFromKey(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "readme-template", Namespace: "argocd"},
+		Data:       map[string]string{"README.md.tmpl": "Repo URL: {{.RepoURL}}\n"},
+	})
+
+	provider := configMapTemplateProvider{Clientset: clientset, Namespace: "argocd", Name: "readme-template", Key: "README.md.tmpl"}
+	tmpl, err := provider.LoadTemplate(context.Background())
+	require.NoError(t, err)
+	assert.NotNil(t, tmpl)
+}
+
+// TestConfigMapTemplateProvider_MissingKeyFailsFast asserts that a ConfigMap missing the configured key is reported
+// as an error rather than silently producing an empty template.
+func TestConfigMapTemplateProvider_MissingKeyFailsFast(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "readme-template", Namespace: "argocd"},
+		Data:       map[string]string{"other-key": "irrelevant"},
+	})
+
+	provider := configMapTemplateProvider{Clientset: clientset, Namespace: "argocd", Name: "readme-template", Key: "README.md.tmpl"}
+	_, err := provider.LoadTemplate(context.Background())
+	require.Error(t, err)
+}
+
+// TestWriteForPaths_BadTemplateFailsBeforeHydratingAnyPath asserts the headline guarantee that WriteForPaths loads
+// and validates the README template up front, so a malformed user template fails the whole operation before any
+// path's manifest.yaml/hydrator.metadata/README.md is written.
+func TestWriteForPaths_BadTemplateFailsBeforeHydratingAnyPath(t *testing.T) {
+	templatePath := filepath.Join(t.TempDir(), "README.md.tmpl")
+	require.NoError(t, os.WriteFile(templatePath, []byte("{{ .Unclosed"), 0o644))
+
+	rootPath := t.TempDir()
+	paths := []*apiclient.PathDetails{
+		{Path: ".", Manifests: []*apiclient.ManifestDetails{
+			{Manifest: `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm","namespace":"default"}}`},
+		}},
+	}
+
+	err := WriteForPaths(context.Background(), rootPath, "https://github.com/example/repo.git", "abc123",
+		OutputFormatFiles, nil, HydrateOptions{}, fileTemplateProvider{Path: templatePath}, paths)
+	require.Error(t, err)
+
+	entries, err := os.ReadDir(rootPath)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "no output should be written when the template fails to load")
+}
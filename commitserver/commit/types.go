@@ -0,0 +1,29 @@
+package commit
+
+// hydratorMetadataSchemaVersion is the current schema version written to every hydrator.metadata file. Bumping it
+// is a signal to consumers that the shape of hydrator.metadata has changed in a way they may need to handle.
+const hydratorMetadataSchemaVersion = "v1"
+
+// hydratorMetadataFile is the contents of a hydrator.metadata file: human-readable provenance describing how a
+// hydrated path (or the hydration as a whole, for the root-level file) was produced.
+type hydratorMetadataFile struct {
+	SchemaVersion string   `json:"schemaVersion"`
+	Commands      []string `json:"commands,omitempty"`
+	DrySHA        string   `json:"drySha"`
+	RepoURL       string   `json:"repoUrl"`
+}
+
+// manifestHydrationReadmeTemplate is the default README.md template executed against a hydratorMetadataFile. It's
+// used unless a TemplateProvider supplies a custom one.
+const manifestHydrationReadmeTemplate = `# Manifest Hydration
+
+To hydrate the manifests in this repository, run the following commands:
+` + "```" + `shell
+{{range $command := .Commands}}{{$command}}
+{{end}}` + "```" + `
+
+## Git Info
+
+* Dry SHA: {{shortSha .DrySHA}}
+* Repo URL: {{.RepoURL}}
+`